@@ -1,13 +1,15 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -18,15 +20,23 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/stefano/minitunnel/internal/protocol"
+	"github.com/hashicorp/yamux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"github.com/stefano/minitunnel/internal/logging"
+	"github.com/stefano/minitunnel/internal/metrics"
+	"github.com/stefano/minitunnel/internal/streamkind"
+	"github.com/stefano/minitunnel/internal/wsconn"
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds the configuration from ~/.minitunnel.yaml
 type Config struct {
-	Server string `yaml:"server"`
-	Token  string `yaml:"token"`
+	Server  string `yaml:"server"`
+	Token   string `yaml:"token"`
+	KeyPath string `yaml:"key_path"`
 }
 
 func loadConfig() (*Config, error) {
@@ -59,6 +69,17 @@ Usage:
   minitunnel [protocol] <port> [flags]
   minitunnel http 8000 --name myservice
   minitunnel 8000 --name myservice
+  minitunnel tcp 2222 --name myssh
+  minitunnel http 8000 --hostname myservice.tunnel.example.com
+
+protocol is one of: http (default), tcp, tls. tcp/tls targets are piped
+byte-for-byte and require the server to be started with a matching
+--tcp-listen/--tcp-service pair. tls is additionally what a server
+running with --tls-passthrough forwards SNI-routed connections as.
+
+--hostname is an alternative to --name for servers started with
+--domain (hostname-based routing): the service name is taken from its
+leftmost label, so the service is reachable at that exact hostname.
 
 Flags:
 `)
@@ -73,18 +94,30 @@ Environment Variables (fallback):
 Config File (~/.minitunnel.yaml):
   server: http://example.com:8888
   token: your-token
+  key_path: /home/you/.minitunnel.key   # alternative to token: Ed25519 identity (no ~ expansion)
 
 Priority: CLI flags > Environment variables > Config file
+
+When --identity-file (or key_path) is set, the client authenticates by
+signing a server-issued nonce instead of sending --token, using
+--principal (default: the service name) as the principal name
+registered in the server's users.yaml.
 `)
 }
 
 func main() {
 	// Define flags
 	var (
-		name   = flag.String("name", "", "Service name for routing (required)")
-		server = flag.String("server", "", "Tunnel server URL")
-		token  = flag.String("token", "", "Authentication token")
-		host   = flag.String("host", "localhost", "Local host to forward to")
+		name         = flag.String("name", "", "Service name for routing (required unless --hostname is set)")
+		server       = flag.String("server", "", "Tunnel server URL")
+		token        = flag.String("token", "", "Authentication token")
+		identityFile = flag.String("identity-file", "", "path to an Ed25519 identity (base64 seed) to authenticate with instead of --token")
+		principal    = flag.String("principal", "", "principal name registered in the server's users.yaml (defaults to --name)")
+		hostname     = flag.String("hostname", "", "full external hostname this service is reachable at under the server's --domain (e.g. myservice.tunnel.example.com); its leftmost label is used as the service name")
+		host         = flag.String("host", "localhost", "Local host to forward to")
+		diagAddr     = flag.String("diagnostic-addr", "", "local address to serve Prometheus /metrics and /tunnel/status on, e.g. :9090 (disabled by default)")
+		logLevel     = flag.String("log-level", "info", "log level: trace, debug, info, warn, error")
+		logFormat    = flag.String("log-format", "console", "log format: json or console")
 	)
 
 	flag.Usage = usage
@@ -98,10 +131,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	log, err := logging.New(*logLevel, *logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
 	// Load config file
 	cfg, err := loadConfig()
 	if err != nil {
-		log.Printf("Warning: failed to load config: %v", err)
+		log.Warn().Err(err).Msg("failed to load config")
 		cfg = &Config{}
 	}
 
@@ -116,12 +155,13 @@ func main() {
 			usage()
 			os.Exit(1)
 		}
+		protocol = "http"
 	case 1:
 		// Just port: "8000"
 		protocol = "http"
 		port = positional[0]
 	case 2:
-		// Protocol and port: "http 8000"
+		// Protocol and port: "http 8000", "tcp 2222"
 		protocol = positional[0]
 		port = positional[1]
 	default:
@@ -130,16 +170,24 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := validateProtocol(protocol); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		usage()
+		os.Exit(1)
+	}
+
 	// Build local target from positional args or env var
 	var localTarget string
 	if port != "" {
-		localTarget = fmt.Sprintf("%s://%s:%s", protocol, *host, port)
+		localTarget = fmt.Sprintf("%s:%s", *host, port)
 	}
 
 	// Resolve configuration with priority: CLI flags > env vars > config file
 	serverURL := resolve(*server, os.Getenv("SERVER_URL"), cfg.Server)
 	authToken := resolve(*token, os.Getenv("AUTH_TOKEN"), cfg.Token)
-	serviceName := resolve(*name, os.Getenv("SERVICE_NAME"), "")
+	identityPath := resolve(*identityFile, os.Getenv("IDENTITY_FILE"), cfg.KeyPath)
+	serviceName := resolve(*name, serviceNameFromHostname(*hostname), os.Getenv("SERVICE_NAME"), "")
+	principalName := resolve(*principal, os.Getenv("PRINCIPAL"), serviceName)
 	if localTarget == "" {
 		localTarget = os.Getenv("LOCAL_TARGET")
 	}
@@ -149,8 +197,8 @@ func main() {
 	if serverURL == "" {
 		missing = append(missing, "server")
 	}
-	if authToken == "" {
-		missing = append(missing, "token")
+	if authToken == "" && identityPath == "" {
+		missing = append(missing, "token or identity-file")
 	}
 	if serviceName == "" {
 		missing = append(missing, "name")
@@ -165,22 +213,29 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Ensure localTarget has scheme
-	if !strings.HasPrefix(localTarget, "http") {
+	// http/https targets are forwarded through net/http and need a
+	// scheme; tcp/tls targets are dialed directly as host:port.
+	if isHTTPProtocol(protocol) && !strings.HasPrefix(localTarget, "http") {
 		localTarget = "http://" + localTarget
 	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
+	var diag *diagnostics
+	if *diagAddr != "" {
+		diag = newDiagnostics(serverURL, serviceName, localTarget)
+		go serveDiagnostics(*diagAddr, diag, log)
+	}
+
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
-		runClient(serverURL, authToken, serviceName, localTarget, stop)
+		runClient(serverURL, authToken, identityPath, principalName, serviceName, protocol, localTarget, diag, log, stop)
 	}()
 
 	<-done
-	log.Println("client shut down")
+	log.Info().Msg("client shut down")
 }
 
 // separateArgs separates positional arguments from flag arguments
@@ -190,6 +245,12 @@ func separateArgs(args []string) (positional, flags []string) {
 		"-name": true, "--name": true,
 		"-server": true, "--server": true,
 		"-token": true, "--token": true,
+		"-identity-file": true, "--identity-file": true,
+		"-principal": true, "--principal": true,
+		"-hostname": true, "--hostname": true,
+		"-diagnostic-addr": true, "--diagnostic-addr": true,
+		"-log-level": true, "--log-level": true,
+		"-log-format": true, "--log-format": true,
 		"-host": true, "--host": true,
 		"-h": true, "--help": true, "-help": true,
 	}
@@ -225,6 +286,17 @@ func separateArgs(args []string) (positional, flags []string) {
 	return
 }
 
+// serviceNameFromHostname derives a service name from --hostname's
+// leftmost label (e.g. "myservice" from "myservice.tunnel.example.com"),
+// matching how the server's hostname router extracts it from r.Host.
+// Returns "" when hostname is unset, so it falls through in resolve().
+func serviceNameFromHostname(hostname string) string {
+	if hostname == "" {
+		return ""
+	}
+	return strings.SplitN(hostname, ".", 2)[0]
+}
+
 // resolve returns the first non-empty value (priority order)
 func resolve(values ...string) string {
 	for _, v := range values {
@@ -235,17 +307,36 @@ func resolve(values ...string) string {
 	return ""
 }
 
-func runClient(serverURL, authToken, serviceName, localTarget string, stop chan os.Signal) {
+func validateProtocol(protocol string) error {
+	switch protocol {
+	case "http", "tcp", "tls":
+		return nil
+	default:
+		return fmt.Errorf("unsupported protocol %q (want http, tcp, or tls)", protocol)
+	}
+}
+
+func isHTTPProtocol(protocol string) bool {
+	return protocol == "http"
+}
+
+func runClient(serverURL, authToken, identityPath, principalName, serviceName, protocol, localTarget string, diag *diagnostics, log zerolog.Logger, stop chan os.Signal) {
 	attempt := 0
 	for {
-		err := connect(serverURL, authToken, serviceName, localTarget, stop)
+		err := connect(serverURL, authToken, identityPath, principalName, serviceName, protocol, localTarget, diag, log, stop)
+		if diag != nil {
+			diag.setConnected(false)
+		}
 		if err == nil {
 			return // clean shutdown
 		}
 
 		attempt++
+		if diag != nil {
+			diag.recordReconnect()
+		}
 		delay := time.Duration(math.Min(float64(time.Second)*math.Pow(2, float64(attempt)), float64(30*time.Second)))
-		log.Printf("connection lost (%v), reconnecting in %s...", err, delay)
+		log.Warn().Err(err).Dur("retry_in", delay).Int("attempt", attempt).Msg("connection lost, reconnecting")
 
 		select {
 		case <-stop:
@@ -255,7 +346,7 @@ func runClient(serverURL, authToken, serviceName, localTarget string, stop chan
 	}
 }
 
-func connect(serverURL, authToken, serviceName, localTarget string, stop chan os.Signal) error {
+func connect(serverURL, authToken, identityPath, principalName, serviceName, protocol, localTarget string, diag *diagnostics, log zerolog.Logger, stop chan os.Signal) error {
 	u, err := url.Parse(serverURL)
 	if err != nil {
 		return err
@@ -270,10 +361,20 @@ func connect(serverURL, authToken, serviceName, localTarget string, stop chan os
 	u.Path = "/ws"
 	q := u.Query()
 	q.Set("service", serviceName)
-	q.Set("token", authToken)
+
+	if identityPath != "" {
+		signature, err := signNonce(serverURL, identityPath, principalName)
+		if err != nil {
+			return fmt.Errorf("pubkey auth: %w", err)
+		}
+		q.Set("principal", principalName)
+		q.Set("signature", signature)
+	} else {
+		q.Set("token", authToken)
+	}
 	u.RawQuery = q.Encode()
 
-	log.Printf("connecting to %s as service '%s'", serverURL, serviceName)
+	log.Info().Str("server", serverURL).Str("service", serviceName).Msg("connecting")
 
 	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
 	if err != nil {
@@ -281,96 +382,312 @@ func connect(serverURL, authToken, serviceName, localTarget string, stop chan os
 	}
 	defer conn.Close()
 
-	log.Printf("connected, forwarding to %s", localTarget)
+	session, err := yamux.Client(wsconn.New(conn), yamux.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	log.Info().Str("local_target", localTarget).Msg("connected")
+	if diag != nil {
+		diag.setConnected(true)
+	}
 
 	// Handle shutdown signal
 	go func() {
 		<-stop
+		session.Close()
 		conn.WriteMessage(websocket.CloseMessage,
 			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
 		conn.Close()
 	}()
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := &http.Client{Timeout: 0} // streaming bodies can outlive a fixed timeout
 
 	for {
-		_, msg, err := conn.ReadMessage()
+		stream, err := session.Accept()
 		if err != nil {
-			// Check if this was a clean shutdown
-			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			select {
+			case <-stop:
 				return nil
+			default:
+				return err
 			}
-			return err
 		}
+		go handleStream(stream, client, protocol, localTarget, diag, log)
+	}
+}
 
-		var req protocol.Request
-		if err := json.Unmarshal(msg, &req); err != nil {
-			log.Printf("unmarshal error: %v", err)
-			continue
-		}
+// handleStream reads the stream-kind tag written by the server when it
+// opened this stream and dispatches to an HTTP or raw TCP handler.
+func handleStream(stream *yamux.Stream, client *http.Client, protocol, localTarget string, diag *diagnostics, log zerolog.Logger) {
+	tag := make([]byte, 1)
+	if _, err := io.ReadFull(stream, tag); err != nil {
+		stream.Close()
+		return
+	}
+
+	switch streamkind.Kind(tag[0]) {
+	case streamkind.TCP:
+		handleTCPStream(stream, localTarget, diag, log)
+	default:
+		handleHTTPStream(stream, client, localTarget, diag, log)
+	}
+}
+
+// handleTCPStream pipes a raw TCP-mode stream straight to the local
+// address bidirectionally, with no HTTP framing involved.
+func handleTCPStream(stream *yamux.Stream, localAddr string, diag *diagnostics, log zerolog.Logger) {
+	defer stream.Close()
+	start := time.Now()
+
+	conn, err := net.Dial("tcp", localAddr)
+	if err != nil {
+		log.Error().Err(err).Str("local_target", localAddr).Msg("tcp dial failed")
+		return
+	}
+	defer conn.Close()
+
+	var bytesIn, bytesOut int64
+	done := make(chan struct{}, 2)
+	go func() {
+		bytesIn, _ = io.Copy(conn, stream)
+		conn.Close()
+		done <- struct{}{}
+	}()
+	go func() {
+		bytesOut, _ = io.Copy(stream, conn)
+		stream.Close()
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
 
-		go handleRequest(conn, client, localTarget, req)
+	if diag != nil {
+		diag.metrics.BytesInTotal.Add(float64(bytesIn))
+		diag.metrics.BytesOutTotal.Add(float64(bytesOut))
 	}
+
+	log.Info().
+		Int64("duration_ms", time.Since(start).Milliseconds()).
+		Int64("bytes_in", bytesIn).
+		Int64("bytes_out", bytesOut).
+		Msg("tcp stream closed")
 }
 
-func handleRequest(conn *websocket.Conn, client *http.Client, localTarget string, req protocol.Request) {
-	targetURL := localTarget + req.Path
+// handleHTTPStream reads one HTTP request off a yamux stream, forwards it
+// to the local service, and writes the HTTP response back on the same
+// stream before closing it.
+func handleHTTPStream(stream *yamux.Stream, client *http.Client, localTarget string, diag *diagnostics, log zerolog.Logger) {
+	defer stream.Close()
+	start := time.Now()
+	reqLog := log.With().Str("request_id", uuid.New().String()).Logger()
 
-	httpReq, err := http.NewRequest(req.Method, targetURL, bytes.NewBufferString(req.Body))
+	req, err := http.ReadRequest(bufio.NewReader(stream))
 	if err != nil {
-		sendError(conn, req.ID, http.StatusBadGateway, "failed to create request: "+err.Error())
+		reqLog.Error().Err(err).Msg("failed to read tunnelled request")
 		return
 	}
+	reqLog = reqLog.With().Str("method", req.Method).Str("path", req.URL.Path).Logger()
 
-	for k, v := range req.Headers {
-		httpReq.Header.Set(k, v)
+	targetURL := localTarget + req.URL.Path
+	if req.URL.RawQuery != "" {
+		targetURL += "?" + req.URL.RawQuery
 	}
 
-	resp, err := client.Do(httpReq)
+	bodyIn := &countingReader{r: req.Body}
+	outReq, err := http.NewRequest(req.Method, targetURL, bodyIn)
 	if err != nil {
-		sendError(conn, req.ID, http.StatusBadGateway, "local request failed: "+err.Error())
+		writeError(stream, http.StatusBadGateway, "failed to create request: "+err.Error())
+		reqLog.Error().Err(err).Msg("failed to create local request")
 		return
 	}
-	defer resp.Body.Close()
+	outReq.Header = req.Header
+	outReq.ContentLength = req.ContentLength
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	resp, err := client.Do(outReq)
 	if err != nil {
-		sendError(conn, req.ID, http.StatusBadGateway, "failed to read response: "+err.Error())
+		writeError(stream, http.StatusBadGateway, "local request failed: "+err.Error())
+		reqLog.Error().Err(err).Msg("local request failed")
 		return
 	}
+	defer resp.Body.Close()
+
+	bodyOut := &countingReader{r: resp.Body}
+	resp.Body = bodyOut
+	resp.Write(stream)
+
+	if diag != nil {
+		diag.metrics.BytesInTotal.Add(float64(bodyIn.n))
+		diag.metrics.BytesOutTotal.Add(float64(bodyOut.n))
+	}
+
+	reqLog.Info().
+		Int("status", resp.StatusCode).
+		Int64("duration_ms", time.Since(start).Milliseconds()).
+		Int64("bytes_in", bodyIn.n).
+		Int64("bytes_out", bodyOut.n).
+		Msg("request forwarded")
+}
+
+// signNonce fetches a single-use challenge from the server's /nonce
+// endpoint and signs it with the identity at identityPath, returning a
+// base64-encoded signature suitable for the /ws "signature" query param.
+func signNonce(serverURL, identityPath, principalName string) (string, error) {
+	key, err := loadIdentity(identityPath)
+	if err != nil {
+		return "", fmt.Errorf("loading identity: %w", err)
+	}
+
+	nonceURL, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	nonceURL.Path = "/nonce"
+	q := nonceURL.Query()
+	q.Set("principal", principalName)
+	nonceURL.RawQuery = q.Encode()
+
+	resp, err := http.Get(nonceURL.String())
+	if err != nil {
+		return "", fmt.Errorf("fetching nonce: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching nonce: server returned %s", resp.Status)
+	}
+
+	var body struct {
+		Nonce string `json:"nonce"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding nonce response: %w", err)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(body.Nonce)
+	if err != nil {
+		return "", fmt.Errorf("decoding nonce: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, nonce)), nil
+}
+
+// loadIdentity reads an Ed25519 identity file containing a base64-encoded
+// 32-byte seed, as generated alongside the public_key configured for this
+// principal in the server's users.yaml.
+func loadIdentity(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("decoding identity file: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("identity file must contain a %d-byte seed, got %d", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
 
-	headers := make(map[string]string)
-	for k, v := range resp.Header {
-		headers[k] = strings.Join(v, ", ")
+func writeError(w io.Writer, status int, msg string) {
+	resp := &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:       io.NopCloser(strings.NewReader(msg)),
 	}
+	resp.Write(w)
+}
+
+// diagnostics backs --diagnostic-addr: a Prometheus registry shared with
+// the server's metric names, plus enough state to answer /tunnel/status
+// without scraping the counters back out.
+type diagnostics struct {
+	metrics *metrics.Metrics
+
+	mu             sync.Mutex
+	server         string
+	service        string
+	localTarget    string
+	connected      bool
+	connectedSince time.Time
+	reconnects     int
+}
 
-	tunnelResp := protocol.Response{
-		ID:      req.ID,
-		Status:  resp.StatusCode,
-		Headers: headers,
-		Body:    string(bodyBytes),
+func newDiagnostics(server, service, localTarget string) *diagnostics {
+	return &diagnostics{metrics: metrics.New(), server: server, service: service, localTarget: localTarget}
+}
+
+func (d *diagnostics) setConnected(connected bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.connected = connected
+	if connected {
+		d.connectedSince = time.Now()
 	}
+}
 
-	data, _ := json.Marshal(tunnelResp)
-	writeConn(conn, data)
+func (d *diagnostics) recordReconnect() {
+	d.mu.Lock()
+	d.reconnects++
+	d.mu.Unlock()
+	d.metrics.ReconnectsTotal.Inc()
 }
 
-var connMu sync.Mutex
+// tunnelStatus is the JSON shape served at /tunnel/status.
+type tunnelStatus struct {
+	Server         string    `json:"server"`
+	Service        string    `json:"service"`
+	LocalTarget    string    `json:"local_target"`
+	Connected      bool      `json:"connected"`
+	ConnectedSince time.Time `json:"connected_since,omitempty"`
+	Reconnects     int       `json:"reconnects"`
+}
 
-func writeConn(conn *websocket.Conn, data []byte) {
-	connMu.Lock()
-	defer connMu.Unlock()
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		log.Printf("write error: %v", err)
+func (d *diagnostics) status() tunnelStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return tunnelStatus{
+		Server:         d.server,
+		Service:        d.service,
+		LocalTarget:    d.localTarget,
+		Connected:      d.connected,
+		ConnectedSince: d.connectedSince,
+		Reconnects:     d.reconnects,
 	}
 }
 
-func sendError(conn *websocket.Conn, id string, status int, msg string) {
-	resp := protocol.Response{
-		ID:     id,
-		Status: status,
-		Body:   msg,
+// serveDiagnostics exposes diag's Prometheus metrics and /tunnel/status
+// on addr until the process exits.
+func serveDiagnostics(addr string, diag *diagnostics, log zerolog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(diag.metrics.Registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/tunnel/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(diag.status())
+	})
+
+	log.Info().Str("addr", addr).Msg("diagnostics listening")
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Error().Err(err).Msg("diagnostics listener exited")
 	}
-	data, _ := json.Marshal(resp)
-	writeConn(conn, data)
 }
+
+// countingReader wraps an io.ReadCloser and tallies the bytes read
+// through it, so request/response logging can report bytes transferred.
+type countingReader struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Close() error { return c.r.Close() }
@@ -1,67 +1,74 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
 	"io"
-	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
-	"github.com/stefano/minitunnel/internal/protocol"
+	"github.com/hashicorp/yamux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+	"github.com/stefano/minitunnel/internal/auth"
+	"github.com/stefano/minitunnel/internal/logging"
+	"github.com/stefano/minitunnel/internal/metrics"
+	"github.com/stefano/minitunnel/internal/sni"
+	"github.com/stefano/minitunnel/internal/streamkind"
+	"github.com/stefano/minitunnel/internal/wsconn"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
-type tunnel struct {
-	conn     *websocket.Conn
-	mu       sync.Mutex
-	pending  map[string]chan protocol.Response
-	pMu     sync.Mutex
-}
-
-func (t *tunnel) send(req protocol.Request) (protocol.Response, error) {
-	ch := make(chan protocol.Response, 1)
-	t.pMu.Lock()
-	t.pending[req.ID] = ch
-	t.pMu.Unlock()
-
-	t.mu.Lock()
-	err := t.conn.WriteJSON(req)
-	t.mu.Unlock()
-	if err != nil {
-		t.pMu.Lock()
-		delete(t.pending, req.ID)
-		t.pMu.Unlock()
-		return protocol.Response{}, err
-	}
+// copyFlushBufSize is the chunk size used when relaying a tunnelled
+// response body back to the original client, so SSE and other
+// long-lived streams show up incrementally instead of all at once.
+const copyFlushBufSize = 32 * 1024
 
-	select {
-	case resp := <-ch:
-		return resp, nil
-	case <-time.After(30 * time.Second):
-		t.pMu.Lock()
-		delete(t.pending, req.ID)
-		t.pMu.Unlock()
-		return protocol.Response{}, http.ErrHandlerTimeout
-	}
+type tunnelEntry struct {
+	session    *yamux.Session
+	owner      string
+	remoteAddr string
+	since      time.Time
+	inFlight   int64
 }
 
 type registry struct {
 	mu      sync.RWMutex
-	tunnels map[string]*tunnel
+	tunnels map[string]*tunnelEntry
+	log     zerolog.Logger
 }
 
-func (r *registry) register(name string, t *tunnel) {
+// register claims name for owner's session. It fails if the name is
+// already claimed by a live tunnel, rather than silently overwriting the
+// existing owner's entry.
+func (r *registry) register(name, owner, remoteAddr string, s *yamux.Session) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.tunnels[name] = t
+	if existing, ok := r.tunnels[name]; ok {
+		return fmt.Errorf("service %q is already registered by %q", name, existing.owner)
+	}
+	r.tunnels[name] = &tunnelEntry{session: s, owner: owner, remoteAddr: remoteAddr, since: time.Now()}
+	return nil
 }
 
 func (r *registry) unregister(name string) {
@@ -70,30 +77,123 @@ func (r *registry) unregister(name string) {
 	delete(r.tunnels, name)
 }
 
-func (r *registry) get(name string) *tunnel {
+func (r *registry) get(name string) *yamux.Session {
+	e := r.getEntry(name)
+	if e == nil {
+		return nil
+	}
+	return e.session
+}
+
+func (r *registry) getEntry(name string) *tunnelEntry {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	return r.tunnels[name]
 }
 
+// tunnelStatus is the /debug/tunnels view of one registered service.
+type tunnelStatus struct {
+	Service        string    `json:"service"`
+	Owner          string    `json:"owner"`
+	RemoteAddr     string    `json:"remote_addr"`
+	ConnectedSince time.Time `json:"connected_since"`
+	InFlight       int64     `json:"in_flight"`
+}
+
+// snapshot returns the status of every registered service, sorted by
+// name for stable output.
+func (r *registry) snapshot() []tunnelStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]tunnelStatus, 0, len(r.tunnels))
+	for name, e := range r.tunnels {
+		out = append(out, tunnelStatus{
+			Service:        name,
+			Owner:          e.owner,
+			RemoteAddr:     e.remoteAddr,
+			ConnectedSince: e.since,
+			InFlight:       atomic.LoadInt64(&e.inFlight),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Service < out[j].Service })
+	return out
+}
+
 func main() {
+	var (
+		tcpListen      = flag.String("tcp-listen", "", "address to listen on for raw TCP tunneling (e.g. :2222)")
+		tcpService     = flag.String("tcp-service", "", "tunnel service name that --tcp-listen connections are forwarded to")
+		logLevel       = flag.String("log-level", "info", "log level: trace, debug, info, warn, error")
+		logFormat      = flag.String("log-format", "console", "log format: json or console")
+		usersFile      = flag.String("users-file", "users.yaml", "path to the users.yaml principal/ACL file")
+		jwtSecret      = flag.String("jwt-secret", os.Getenv("JWT_SECRET"), "HS256 secret used to verify JWT tokens")
+		jwtPublicFile  = flag.String("jwt-public-key-file", os.Getenv("JWT_PUBLIC_KEY_FILE"), "path to an Ed25519 public key (PEM) used to verify EdDSA JWT tokens")
+		domain         = flag.String("domain", "", "base domain for hostname-based routing (e.g. tunnel.example.com); enables autocert TLS unless --tls-passthrough is set")
+		acmeEmail      = flag.String("acme-email", "", "contact email for the Let's Encrypt account used by autocert")
+		tlsPassthrough = flag.Bool("tls-passthrough", false, "peek the SNI hostname and pipe raw TLS bytes to a tls tunnel client instead of terminating TLS here (requires --domain)")
+		tlsListen      = flag.String("tls-listen", ":443", "address to listen on for --tls-passthrough")
+	)
+	flag.Parse()
+
+	log, err := logging.New(*logLevel, *logFormat)
+	if err != nil {
+		panic(err)
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	authToken := os.Getenv("AUTH_TOKEN")
-	if authToken == "" {
-		log.Fatal("AUTH_TOKEN environment variable is required")
+
+	authenticator, nonces, err := buildAuthenticator(*usersFile, *jwtSecret, *jwtPublicFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to set up authentication")
 	}
 
-	reg := &registry{tunnels: make(map[string]*tunnel)}
+	reg := &registry{tunnels: make(map[string]*tunnelEntry), log: log}
+	m := metrics.New()
+
+	if *tcpListen != "" {
+		if *tcpService == "" {
+			log.Fatal().Msg("--tcp-service is required when --tcp-listen is set")
+		}
+		go serveTCP(*tcpListen, *tcpService, reg, m, log)
+	}
 
 	mux := http.NewServeMux()
 
+	mux.Handle("/metrics", promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{}))
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	})
+
+	mux.HandleFunc("/debug/tunnels", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reg.snapshot())
+	})
+
+	if nonces != nil {
+		mux.HandleFunc("/nonce", func(w http.ResponseWriter, r *http.Request) {
+			principal := r.URL.Query().Get("principal")
+			if principal == "" {
+				http.Error(w, "principal query param required", http.StatusBadRequest)
+				return
+			}
+			nonce, err := nonces.Issue(principal)
+			if err != nil {
+				http.Error(w, "failed to issue nonce", http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprintf(w, `{"nonce":%q}`, nonce)
+		})
+	}
+
 	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		token := r.URL.Query().Get("token")
-		if token != authToken {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
 			return
 		}
 		service := r.URL.Query().Get("service")
@@ -101,110 +201,432 @@ func main() {
 			http.Error(w, "service query param required", http.StatusBadRequest)
 			return
 		}
+		if !principal.Allows(service) {
+			http.Error(w, fmt.Sprintf("principal %q is not allowed to register service %q", principal.Name, service), http.StatusForbidden)
+			return
+		}
 
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			log.Printf("websocket upgrade error: %v", err)
+			log.Error().Err(err).Msg("websocket upgrade error")
 			return
 		}
 
-		t := &tunnel{
-			conn:    conn,
-			pending: make(map[string]chan protocol.Response),
+		session, err := yamux.Server(wsconn.New(conn), yamux.DefaultConfig())
+		if err != nil {
+			log.Error().Err(err).Msg("yamux server setup error")
+			conn.Close()
+			return
 		}
-		reg.register(service, t)
-		log.Printf("tunnel registered: %s", service)
 
-		defer func() {
-			reg.unregister(service)
+		if err := reg.register(service, principal.Name, r.RemoteAddr, session); err != nil {
+			log.Warn().Err(err).Str("service", service).Str("principal", principal.Name).Msg("tunnel registration rejected")
+			session.Close()
 			conn.Close()
-			log.Printf("tunnel unregistered: %s", service)
+			return
+		}
+		m.TunnelsRegistered.WithLabelValues(service).Set(1)
+		log.Info().Str("service", service).Str("principal", principal.Name).Str("remote_addr", r.RemoteAddr).Msg("tunnel registered")
+
+		<-session.CloseChan()
+		reg.unregister(service)
+		m.TunnelsRegistered.WithLabelValues(service).Set(0)
+		conn.Close()
+		log.Info().Str("service", service).Str("principal", principal.Name).Str("remote_addr", r.RemoteAddr).Msg("tunnel unregistered")
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := uuid.New().String()
+		reqLog := log.With().Str("request_id", id).Logger()
+
+		service, remainder, err := resolveService(r, *domain)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			reqLog.Warn().Err(err).Str("host", r.Host).Str("path", r.URL.Path).Msg("routing failed")
+			return
+		}
+		reqLog = reqLog.With().Str("service", service).Str("method", r.Method).Str("path", remainder).Logger()
+
+		entry := reg.getEntry(service)
+		if entry == nil {
+			http.Error(w, "service not found: "+service, http.StatusBadGateway)
+			reqLog.Warn().Msg("service not found")
+			return
+		}
+
+		m.PendingRequests.WithLabelValues(service).Inc()
+		atomic.AddInt64(&entry.inFlight, 1)
+		defer func() {
+			atomic.AddInt64(&entry.inFlight, -1)
+			m.PendingRequests.WithLabelValues(service).Dec()
 		}()
 
-		// Read responses from client
+		stream, err := entry.session.Open()
+		if err != nil {
+			http.Error(w, "tunnel error: "+err.Error(), http.StatusBadGateway)
+			reqLog.Error().Err(err).Msg("tunnel open error")
+			return
+		}
+		defer stream.Close()
+
+		go func() {
+			<-r.Context().Done()
+			stream.Close()
+		}()
+
+		if _, err := stream.Write([]byte{byte(streamkind.HTTP)}); err != nil {
+			http.Error(w, "tunnel write error: "+err.Error(), http.StatusBadGateway)
+			reqLog.Error().Err(err).Msg("tunnel write error")
+			return
+		}
+
+		bodyIn := &countingReader{r: r.Body}
+		outReq := &http.Request{
+			Method:        r.Method,
+			URL:           &url.URL{Path: remainder, RawQuery: r.URL.RawQuery},
+			Header:        r.Header.Clone(),
+			Body:          bodyIn,
+			Host:          r.Host,
+			ContentLength: r.ContentLength,
+			Trailer:       r.Trailer,
+		}
+		if err := outReq.Write(stream); err != nil {
+			http.Error(w, "tunnel write error: "+err.Error(), http.StatusBadGateway)
+			reqLog.Error().Err(err).Msg("tunnel write error")
+			return
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(stream), outReq)
+		if err != nil {
+			http.Error(w, "tunnel read error: "+err.Error(), http.StatusBadGateway)
+			reqLog.Error().Err(err).Msg("tunnel read error")
+			return
+		}
+		defer resp.Body.Close()
+
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+
+		flusher, _ := w.(http.Flusher)
+		bytesOut := 0
+		buf := make([]byte, copyFlushBufSize)
 		for {
-			_, msg, err := conn.ReadMessage()
-			if err != nil {
-				log.Printf("tunnel %s read error: %v", service, err)
-				return
-			}
-			var resp protocol.Response
-			if err := json.Unmarshal(msg, &resp); err != nil {
-				log.Printf("tunnel %s unmarshal error: %v", service, err)
-				continue
-			}
-			t.pMu.Lock()
-			ch, ok := t.pending[resp.ID]
-			if ok {
-				delete(t.pending, resp.ID)
+			n, rerr := resp.Body.Read(buf)
+			if n > 0 {
+				w.Write(buf[:n])
+				bytesOut += n
+				if flusher != nil {
+					flusher.Flush()
+				}
 			}
-			t.pMu.Unlock()
-			if ok {
-				ch <- resp
+			if rerr != nil {
+				break
 			}
 		}
+
+		duration := time.Since(start)
+		m.RequestsTotal.WithLabelValues(service, r.Method, strconv.Itoa(resp.StatusCode)).Inc()
+		m.RequestDuration.WithLabelValues(service).Observe(duration.Seconds())
+		m.BytesInTotal.Add(float64(bodyIn.n))
+		m.BytesOutTotal.Add(float64(bytesOut))
+
+		reqLog.Info().
+			Int("status", resp.StatusCode).
+			Int64("duration_ms", duration.Milliseconds()).
+			Int64("bytes_in", bodyIn.n).
+			Int("bytes_out", bytesOut).
+			Msg("request proxied")
 	})
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Parse /<service>/rest/of/path
-		path := strings.TrimPrefix(r.URL.Path, "/")
-		parts := strings.SplitN(path, "/", 2)
-		if len(parts) == 0 || parts[0] == "" {
-			http.Error(w, "no service specified in path", http.StatusBadRequest)
-			return
+	switch {
+	case *domain == "":
+		log.Info().Str("port", port).Msg("minitunnel server listening")
+		if err := http.ListenAndServe(":"+port, mux); err != nil {
+			log.Fatal().Err(err).Msg("server exited")
 		}
-		service := parts[0]
-		remainder := "/"
-		if len(parts) == 2 {
-			remainder = "/" + parts[1]
+
+	case *tlsPassthrough:
+		go serveTLSPassthrough(*tlsListen, *domain, reg, m, log)
+		log.Info().Str("port", port).Str("tls_listen", *tlsListen).Str("domain", *domain).Msg("minitunnel server listening (TLS passthrough)")
+		if err := http.ListenAndServe(":"+port, mux); err != nil {
+			log.Fatal().Err(err).Msg("server exited")
 		}
 
-		t := reg.get(service)
-		if t == nil {
-			http.Error(w, "service not found: "+service, http.StatusBadGateway)
-			return
+	default:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache("certs"),
+			Email:      *acmeEmail,
+			HostPolicy: subdomainHostPolicy(*domain),
 		}
+		// autocert's HTTP-01 challenge must be served in plaintext on :80;
+		// everything else falls through to the normal handler over TLS.
+		go func() {
+			if err := http.ListenAndServe(":80", manager.HTTPHandler(nil)); err != nil {
+				log.Error().Err(err).Msg("acme challenge listener exited")
+			}
+		}()
+
+		server := &http.Server{
+			Addr:      ":443",
+			Handler:   mux,
+			TLSConfig: manager.TLSConfig(),
+		}
+		log.Info().Str("domain", *domain).Msg("minitunnel server listening (TLS via autocert)")
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			log.Fatal().Err(err).Msg("server exited")
+		}
+	}
+}
+
+// subdomainHostPolicy restricts autocert to issuing certificates for
+// domain and any subdomain of it, at any depth. It is per-service rather
+// than a true wildcard because ACME's HTTP-01 challenge cannot prove
+// control of a wildcard name; each first connection to a new subdomain
+// triggers its own certificate request, cached in ./certs thereafter.
+func subdomainHostPolicy(domain string) autocert.HostPolicy {
+	return func(ctx context.Context, host string) error {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+		return fmt.Errorf("acme/autocert: host %q is not %q or a subdomain of it", host, domain)
+	}
+}
 
-		bodyBytes, err := io.ReadAll(r.Body)
+// serveTLSPassthrough accepts raw TLS connections on addr, peeks the SNI
+// server name from each ClientHello, and pipes the connection untouched
+// to the service whose name matches the SNI hostname's leftmost label,
+// the same way --tcp-listen forwards a fixed service.
+func serveTLSPassthrough(addr, domain string, reg *registry, m *metrics.Metrics, log zerolog.Logger) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal().Err(err).Str("addr", addr).Msg("tls passthrough listen failed")
+	}
+	log.Info().Str("addr", addr).Msg("tls passthrough listening")
+
+	for {
+		conn, err := ln.Accept()
 		if err != nil {
-			http.Error(w, "failed to read body", http.StatusInternalServerError)
+			log.Error().Err(err).Msg("tls passthrough accept error")
 			return
 		}
+		go func(conn net.Conn) {
+			peeked, serverName, err := sni.Peek(conn)
+			if err != nil {
+				log.Warn().Err(err).Str("remote_addr", conn.RemoteAddr().String()).Msg("sni peek failed")
+				conn.Close()
+				return
+			}
+			service, err := serviceFromHost(serverName, domain)
+			if err != nil {
+				log.Warn().Err(err).Str("sni", serverName).Msg("tls passthrough routing failed")
+				conn.Close()
+				return
+			}
+			handleTCPConn(peeked, service, reg, m, log)
+		}(conn)
+	}
+}
 
-		headers := make(map[string]string)
-		for k, v := range r.Header {
-			headers[k] = strings.Join(v, ", ")
+// resolveService extracts the target service name and remaining request
+// path from r. With domain set, it routes by the leftmost label of
+// r.Host (e.g. "myservice" from "myservice.tunnel.example.com") and
+// leaves r.URL.Path untouched, so cookies scoped to "/" and absolute
+// URLs generated by the backed service keep working. With domain
+// empty, it falls back to the legacy /<service>/rest/of/path scheme.
+func resolveService(r *http.Request, domain string) (service, remainder string, err error) {
+	if domain != "" {
+		service, err = serviceFromHost(r.Host, domain)
+		if err != nil {
+			return "", "", err
+		}
+		remainder = r.URL.Path
+		if remainder == "" {
+			remainder = "/"
 		}
+		return service, remainder, nil
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) == 0 || parts[0] == "" {
+		return "", "", fmt.Errorf("no service specified in path")
+	}
+	remainder = "/"
+	if len(parts) == 2 {
+		remainder = "/" + parts[1]
+	}
+	return parts[0], remainder, nil
+}
 
-		// Preserve query string
-		forwardPath := remainder
-		if r.URL.RawQuery != "" {
-			forwardPath += "?" + r.URL.RawQuery
+// serviceFromHost extracts the service name from the leftmost label of
+// host, requiring the remainder to match domain exactly (e.g. host
+// "myservice.tunnel.example.com" with domain "tunnel.example.com"
+// yields "myservice").
+func serviceFromHost(host, domain string) (string, error) {
+	if h, _, splitErr := net.SplitHostPort(host); splitErr == nil {
+		host = h
+	}
+	suffix := "." + domain
+	if !strings.HasSuffix(host, suffix) {
+		return "", fmt.Errorf("host %q is not a subdomain of %q", host, domain)
+	}
+	label := strings.TrimSuffix(host, suffix)
+	if label == "" || strings.Contains(label, ".") {
+		return "", fmt.Errorf("host %q must have exactly one service label under %q", host, domain)
+	}
+	return label, nil
+}
+
+// buildAuthenticator assembles the server's Authenticator from the
+// available configuration. A users.yaml enables the full
+// ServerAuthenticator (static tokens, JWT, and Ed25519 pubkey
+// challenges); when that file does not exist it falls back to the legacy
+// shared AUTH_TOKEN so existing deployments keep working until they add
+// a users.yaml. Any other error reading or parsing it is returned. The
+// returned *auth.NonceStore is nil when pubkey auth isn't available, so
+// callers know not to expose the /nonce endpoint.
+func buildAuthenticator(usersFile, jwtSecret, jwtPublicKeyFile string) (auth.Authenticator, *auth.NonceStore, error) {
+	users, err := auth.LoadUsersFile(usersFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("loading users file: %w", err)
+		}
+		if token := os.Getenv("AUTH_TOKEN"); token != "" {
+			return auth.LegacyTokenAuthenticator{Token: token}, nil, nil
 		}
+		return nil, nil, fmt.Errorf("no %s found and AUTH_TOKEN is not set", usersFile)
+	}
 
-		req := protocol.Request{
-			ID:      uuid.New().String(),
-			Method:  r.Method,
-			Path:    forwardPath,
-			Headers: headers,
-			Body:    string(bodyBytes),
+	var jwtAuth *auth.JWTAuthenticator
+	switch {
+	case jwtPublicKeyFile != "":
+		pub, err := loadEd25519PublicKey(jwtPublicKeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading jwt public key: %w", err)
 		}
+		jwtAuth = &auth.JWTAuthenticator{Key: pub}
+	case jwtSecret != "":
+		jwtAuth = &auth.JWTAuthenticator{Key: []byte(jwtSecret)}
+	}
+
+	nonces := auth.NewNonceStore()
+	return &auth.ServerAuthenticator{Users: users, JWT: jwtAuth, Nonces: nonces}, nonces, nil
+}
+
+// loadEd25519PublicKey reads a PEM-encoded Ed25519 public key used to
+// verify EdDSA-signed JWTs.
+func loadEd25519PublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not contain an Ed25519 public key", path)
+	}
+	return key, nil
+}
+
+// countingReader wraps an io.ReadCloser and tallies the bytes read
+// through it, so the proxy handler can log bytes_in once the body has
+// been fully streamed to the tunnel.
+type countingReader struct {
+	r io.ReadCloser
+	n int64
+}
 
-		resp, err := t.send(req)
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Close() error { return c.r.Close() }
+
+// serveTCP accepts raw TCP connections on addr and pipes each one to a
+// fresh yamux stream on the named service's tunnel, tagged so the
+// client forwards it to a local TCP socket instead of treating it as
+// HTTP.
+func serveTCP(addr, service string, reg *registry, m *metrics.Metrics, log zerolog.Logger) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal().Err(err).Str("addr", addr).Msg("tcp listen failed")
+	}
+	log.Info().Str("addr", addr).Str("service", service).Msg("tcp tunnel listening")
+
+	for {
+		conn, err := ln.Accept()
 		if err != nil {
-			http.Error(w, "tunnel error: "+err.Error(), http.StatusBadGateway)
+			log.Error().Err(err).Msg("tcp accept error")
 			return
 		}
+		go handleTCPConn(conn, service, reg, m, log)
+	}
+}
 
-		for k, v := range resp.Headers {
-			w.Header().Set(k, v)
-		}
-		w.WriteHeader(resp.Status)
-		w.Write([]byte(resp.Body))
-	})
+func handleTCPConn(conn net.Conn, service string, reg *registry, m *metrics.Metrics, log zerolog.Logger) {
+	defer conn.Close()
+	start := time.Now()
+	connLog := log.With().Str("service", service).Str("remote_addr", conn.RemoteAddr().String()).Logger()
+
+	entry := reg.getEntry(service)
+	if entry == nil {
+		connLog.Warn().Msg("tcp conn for unknown service")
+		return
+	}
+
+	m.PendingRequests.WithLabelValues(service).Inc()
+	atomic.AddInt64(&entry.inFlight, 1)
+	defer func() {
+		atomic.AddInt64(&entry.inFlight, -1)
+		m.PendingRequests.WithLabelValues(service).Dec()
+	}()
+
+	stream, err := entry.session.Open()
+	if err != nil {
+		connLog.Error().Err(err).Msg("tcp tunnel open error")
+		return
+	}
+	defer stream.Close()
 
-	log.Printf("minitunnel server listening on :%s", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
-		log.Fatal(err)
+	if _, err := stream.Write([]byte{byte(streamkind.TCP)}); err != nil {
+		connLog.Error().Err(err).Msg("tcp tunnel write error")
+		return
 	}
+
+	var bytesIn, bytesOut int64
+	done := make(chan struct{}, 2)
+	go func() {
+		bytesIn, _ = io.Copy(stream, conn)
+		stream.Close()
+		done <- struct{}{}
+	}()
+	go func() {
+		bytesOut, _ = io.Copy(conn, stream)
+		conn.Close()
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+
+	m.BytesInTotal.Add(float64(bytesIn))
+	m.BytesOutTotal.Add(float64(bytesOut))
+
+	connLog.Info().
+		Int64("duration_ms", time.Since(start).Milliseconds()).
+		Int64("bytes_in", bytesIn).
+		Int64("bytes_out", bytesOut).
+		Msg("tcp connection closed")
 }
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestRegistryRegisterRejectsCollision(t *testing.T) {
+	r := &registry{tunnels: make(map[string]*tunnelEntry)}
+
+	if err := r.register("web", "alice", "10.0.0.1:1234", nil); err != nil {
+		t.Fatalf("first register: %v", err)
+	}
+	if err := r.register("web", "mallory", "10.0.0.2:1234", nil); err == nil {
+		t.Fatal("register allowed a second owner to claim an already-registered service name")
+	}
+	if got := r.getEntry("web").owner; got != "alice" {
+		t.Fatalf("owner = %q, want %q (collision must not overwrite the existing entry)", got, "alice")
+	}
+}
+
+func TestRegistryRegisterAfterUnregister(t *testing.T) {
+	r := &registry{tunnels: make(map[string]*tunnelEntry)}
+
+	if err := r.register("web", "alice", "10.0.0.1:1234", nil); err != nil {
+		t.Fatalf("first register: %v", err)
+	}
+	r.unregister("web")
+	if err := r.register("web", "mallory", "10.0.0.2:1234", nil); err != nil {
+		t.Fatalf("register after unregister: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+// Package auth authenticates incoming tunnel registrations and decides
+// which service names a given principal is allowed to claim, replacing
+// the single shared AUTH_TOKEN that used to let any client claim any
+// service name.
+package auth
+
+import (
+	"net/http"
+	"path/filepath"
+)
+
+// Principal is the authenticated identity of a connecting tunnel client.
+type Principal struct {
+	Name            string
+	AllowedServices []string // glob patterns, matched against the requested service name
+}
+
+// Allows reports whether the principal may register the given service name.
+func (p Principal) Allows(service string) bool {
+	for _, pattern := range p.AllowedServices {
+		if ok, _ := filepath.Match(pattern, service); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator validates an incoming /ws registration request and
+// returns the principal it authenticated as.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
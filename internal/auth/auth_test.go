@@ -0,0 +1,30 @@
+package auth
+
+import "testing"
+
+func TestPrincipalAllows(t *testing.T) {
+	p := Principal{Name: "alice", AllowedServices: []string{"web-*", "api"}}
+
+	cases := []struct {
+		service string
+		want    bool
+	}{
+		{"web-frontend", true},
+		{"web-", true},
+		{"api", true},
+		{"apiv2", false},
+		{"ssh", false},
+	}
+	for _, c := range cases {
+		if got := p.Allows(c.service); got != c.want {
+			t.Errorf("Allows(%q) = %v, want %v", c.service, got, c.want)
+		}
+	}
+}
+
+func TestPrincipalAllowsWildcard(t *testing.T) {
+	p := Principal{Name: "admin", AllowedServices: []string{"*"}}
+	if !p.Allows("anything") {
+		t.Fatal("wildcard principal should be allowed to register any service")
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the custom fields minitunnel expects in a tunnel JWT, on
+// top of the standard registered claims (sub, exp, ...).
+type Claims struct {
+	AllowedServices []string `json:"allowed_services"`
+	jwt.RegisteredClaims
+}
+
+// JWTAuthenticator verifies tokens signed with either HS256 (Key is a
+// []byte secret) or EdDSA (Key is an ed25519.PublicKey).
+type JWTAuthenticator struct {
+	Key any
+}
+
+// Verify parses and validates tokenStr, returning the Principal it
+// describes once its signature and expiry check out.
+func (a JWTAuthenticator) Verify(tokenStr string) (Principal, error) {
+	var claims Claims
+	token, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (any, error) {
+		switch key := a.Key.(type) {
+		case []byte:
+			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v for HMAC key", t.Header["alg"])
+			}
+			return key, nil
+		case ed25519.PublicKey:
+			if _, ok := t.Method.(*jwt.SigningMethodEd25519); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v for Ed25519 key", t.Header["alg"])
+			}
+			return key, nil
+		default:
+			return nil, fmt.Errorf("unsupported key type %T configured", a.Key)
+		}
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("invalid token: %w", err)
+	}
+	if !token.Valid {
+		return Principal{}, fmt.Errorf("invalid token")
+	}
+	return Principal{Name: claims.Subject, AllowedServices: claims.AllowedServices}, nil
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signHS256(t *testing.T, claims Claims, secret []byte) string {
+	t.Helper()
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatalf("sign HS256 token: %v", err)
+	}
+	return tok
+}
+
+func TestJWTAuthenticatorVerifyHMAC(t *testing.T) {
+	secret := []byte("top-secret")
+	auth := JWTAuthenticator{Key: secret}
+	claims := Claims{
+		AllowedServices: []string{"web-*"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	tok := signHS256(t, claims, secret)
+
+	p, err := auth.Verify(tok)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if p.Name != "alice" || len(p.AllowedServices) != 1 || p.AllowedServices[0] != "web-*" {
+		t.Fatalf("unexpected principal: %+v", p)
+	}
+}
+
+func TestJWTAuthenticatorVerifyEdDSA(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	auth := JWTAuthenticator{Key: ed25519.PublicKey(pub)}
+	claims := Claims{
+		AllowedServices: []string{"api"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "bob",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(priv)
+	if err != nil {
+		t.Fatalf("sign EdDSA token: %v", err)
+	}
+
+	p, err := auth.Verify(tok)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if p.Name != "bob" {
+		t.Fatalf("unexpected principal: %+v", p)
+	}
+}
+
+// TestJWTAuthenticatorRejectsAlgorithmConfusion guards against a keyfunc
+// that hands the same key bytes to both HMAC and EdDSA verification: an
+// attacker who knows an Ed25519 public key (not a secret) could otherwise
+// forge an HS256 token MACed with those bytes and have it accepted.
+func TestJWTAuthenticatorRejectsAlgorithmConfusion(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	auth := JWTAuthenticator{Key: ed25519.PublicKey(pub)}
+
+	claims := Claims{
+		AllowedServices: []string{"*"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "attacker",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+	forged := signHS256(t, claims, pub)
+
+	if _, err := auth.Verify(forged); err == nil {
+		t.Fatal("Verify accepted an HS256 token signed with the configured Ed25519 public key")
+	}
+}
+
+func TestJWTAuthenticatorRejectsExpired(t *testing.T) {
+	secret := []byte("top-secret")
+	auth := JWTAuthenticator{Key: secret}
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "alice",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+	}
+	tok := signHS256(t, claims, secret)
+
+	if _, err := auth.Verify(tok); err == nil {
+		t.Fatal("Verify accepted an expired token")
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// nonceTTL bounds how long an issued challenge stays valid.
+const nonceTTL = 30 * time.Second
+
+// NonceStore issues and verifies the single-use nonces principals sign
+// to prove control of an Ed25519 key, without a full interactive
+// handshake over the control websocket.
+type NonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]nonceEntry
+}
+
+type nonceEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewNonceStore returns an empty NonceStore.
+func NewNonceStore() *NonceStore {
+	return &NonceStore{nonces: make(map[string]nonceEntry)}
+}
+
+// Issue generates and stores a fresh nonce for principal, returning it
+// base64-encoded for transport over HTTP.
+func (s *NonceStore) Issue(principal string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.nonces[principal] = nonceEntry{value: buf, expires: time.Now().Add(nonceTTL)}
+	s.mu.Unlock()
+
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// Verify checks signature against the nonce most recently issued for
+// principal and consumes it, so a challenge can only be answered once.
+func (s *NonceStore) Verify(principal string, publicKey ed25519.PublicKey, signature []byte) error {
+	s.mu.Lock()
+	entry, ok := s.nonces[principal]
+	delete(s.nonces, principal)
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no pending challenge for principal %q", principal)
+	}
+	if time.Now().After(entry.expires) {
+		return fmt.Errorf("challenge for principal %q expired", principal)
+	}
+	if !ed25519.Verify(publicKey, entry.value, signature) {
+		return fmt.Errorf("signature verification failed for principal %q", principal)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestNonceStoreIssueAndVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s := NewNonceStore()
+
+	nonceB64, err := s.Issue("alice")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	sig := ed25519.Sign(priv, mustDecodeNonce(t, nonceB64))
+
+	if err := s.Verify("alice", pub, sig); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestNonceStoreVerifyIsSingleUse(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s := NewNonceStore()
+
+	nonceB64, err := s.Issue("alice")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	sig := ed25519.Sign(priv, mustDecodeNonce(t, nonceB64))
+
+	if err := s.Verify("alice", pub, sig); err != nil {
+		t.Fatalf("first Verify: %v", err)
+	}
+	if err := s.Verify("alice", pub, sig); err == nil {
+		t.Fatal("Verify accepted a replayed signature for an already-consumed nonce")
+	}
+}
+
+func TestNonceStoreVerifyRejectsWrongKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s := NewNonceStore()
+
+	nonceB64, err := s.Issue("alice")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	sig := ed25519.Sign(priv, mustDecodeNonce(t, nonceB64))
+
+	if err := s.Verify("alice", otherPub, sig); err == nil {
+		t.Fatal("Verify accepted a signature under the wrong public key")
+	}
+}
+
+func TestNonceStoreVerifyRejectsExpired(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s := NewNonceStore()
+
+	nonceB64, err := s.Issue("alice")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	sig := ed25519.Sign(priv, mustDecodeNonce(t, nonceB64))
+
+	s.mu.Lock()
+	entry := s.nonces["alice"]
+	entry.expires = time.Now().Add(-time.Second)
+	s.nonces["alice"] = entry
+	s.mu.Unlock()
+
+	if err := s.Verify("alice", pub, sig); err == nil {
+		t.Fatal("Verify accepted a signature for an expired nonce")
+	}
+}
+
+func mustDecodeNonce(t *testing.T, nonceB64 string) []byte {
+	t.Helper()
+	b, err := base64.StdEncoding.DecodeString(nonceB64)
+	if err != nil {
+		t.Fatalf("decode nonce: %v", err)
+	}
+	return b
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// ServerAuthenticator is the server's pluggable Authenticator: it checks
+// an incoming /ws request against users.yaml, accepting a signed JWT, a
+// per-user static token, or an Ed25519 nonce signature.
+type ServerAuthenticator struct {
+	Users  *UsersFile
+	JWT    *JWTAuthenticator // nil disables JWT verification
+	Nonces *NonceStore
+}
+
+func (a *ServerAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	q := r.URL.Query()
+
+	if tok := q.Get("token"); tok != "" {
+		return a.authenticateToken(tok)
+	}
+	if principal := q.Get("principal"); principal != "" {
+		return a.authenticatePubkey(principal, q.Get("signature"))
+	}
+	return Principal{}, fmt.Errorf("no credentials supplied")
+}
+
+func (a *ServerAuthenticator) authenticateToken(tok string) (Principal, error) {
+	if a.JWT != nil {
+		if p, err := a.JWT.Verify(tok); err == nil {
+			return p, nil
+		}
+	}
+	for name, u := range a.Users.Users {
+		if u.Token != "" && u.Token == tok {
+			return Principal{Name: name, AllowedServices: u.AllowedServices}, nil
+		}
+	}
+	return Principal{}, fmt.Errorf("invalid token")
+}
+
+func (a *ServerAuthenticator) authenticatePubkey(principal, signatureB64 string) (Principal, error) {
+	u, ok := a.Users.Users[principal]
+	if !ok || u.PublicKey == "" {
+		return Principal{}, fmt.Errorf("unknown principal %q", principal)
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(u.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return Principal{}, fmt.Errorf("invalid public key configured for %q", principal)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return Principal{}, fmt.Errorf("invalid signature encoding")
+	}
+	if err := a.Nonces.Verify(principal, ed25519.PublicKey(pub), sig); err != nil {
+		return Principal{}, err
+	}
+	return Principal{Name: principal, AllowedServices: u.AllowedServices}, nil
+}
+
+// LegacyTokenAuthenticator authenticates every request against a single
+// shared secret, carried over from the pre-users.yaml AUTH_TOKEN model
+// so existing deployments keep working until they migrate.
+type LegacyTokenAuthenticator struct {
+	Token string
+}
+
+func (a LegacyTokenAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	if r.URL.Query().Get("token") != a.Token {
+		return Principal{}, fmt.Errorf("invalid token")
+	}
+	return Principal{Name: "legacy", AllowedServices: []string{"*"}}, nil
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserConfig is one principal's entry in users.yaml. A principal is
+// expected to authenticate with exactly one of Token or PublicKey.
+type UserConfig struct {
+	Token           string   `yaml:"token,omitempty"`
+	PublicKey       string   `yaml:"public_key,omitempty"` // base64-encoded Ed25519 public key
+	AllowedServices []string `yaml:"allowed_services"`
+	RateLimit       int      `yaml:"rate_limit,omitempty"` // requests/sec; loaded from users.yaml but not enforced by the server
+}
+
+// UsersFile is the server's users.yaml: a map of principal name to its
+// credentials, allowed service-name patterns, and rate limit bucket.
+type UsersFile struct {
+	Users map[string]UserConfig `yaml:"users"`
+}
+
+// LoadUsersFile reads and parses a users.yaml from disk.
+func LoadUsersFile(path string) (*UsersFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f UsersFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
@@ -0,0 +1,36 @@
+// Package logging builds the zerolog.Logger shared by the minitunnel
+// server and client, configured from the --log-level/--log-format flags
+// both binaries expose.
+package logging
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// New returns a logger writing to stderr at the given level, either as
+// newline-delimited JSON ("json") or a human-readable console format
+// ("console").
+func New(level, format string) (zerolog.Logger, error) {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return zerolog.Logger{}, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	var w interface {
+		Write([]byte) (int, error)
+	} = os.Stderr
+
+	switch format {
+	case "json":
+		// zerolog already writes JSON by default.
+	case "console", "":
+		w = zerolog.ConsoleWriter{Out: os.Stderr}
+	default:
+		return zerolog.Logger{}, fmt.Errorf("invalid log format %q (want json or console)", format)
+	}
+
+	return zerolog.New(w).Level(lvl).With().Timestamp().Logger(), nil
+}
@@ -0,0 +1,69 @@
+// Package metrics defines the Prometheus collectors shared by the
+// minitunnel server and client, so both expose the same metric names
+// under their respective /metrics endpoints.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Metrics holds every collector minitunnel exports, registered against
+// its own Registry rather than the global default so server and client
+// instances never collide when embedded in the same process (tests,
+// single-binary deployments).
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	TunnelsRegistered *prometheus.GaugeVec
+	RequestsTotal     *prometheus.CounterVec
+	RequestDuration   *prometheus.HistogramVec
+	BytesInTotal      prometheus.Counter
+	BytesOutTotal     prometheus.Counter
+	PendingRequests   *prometheus.GaugeVec
+	ReconnectsTotal   prometheus.Counter
+}
+
+// New returns a Metrics with every collector created and registered.
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		TunnelsRegistered: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "minitunnel_tunnels_registered",
+			Help: "Whether a service currently has a live tunnel registered (1) or not (0).",
+		}, []string{"service"}),
+		RequestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "minitunnel_requests_total",
+			Help: "Total HTTP requests proxied through a tunnel.",
+		}, []string{"service", "method", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "minitunnel_request_duration_seconds",
+			Help:    "Duration of proxied HTTP requests in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"service"}),
+		BytesInTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "minitunnel_bytes_in_total",
+			Help: "Total bytes read from the tunnel into the proxy.",
+		}),
+		BytesOutTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "minitunnel_bytes_out_total",
+			Help: "Total bytes written back out through the proxy.",
+		}),
+		PendingRequests: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "minitunnel_pending_requests",
+			Help: "In-flight proxied requests per service.",
+		}, []string{"service"}),
+		ReconnectsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "minitunnel_reconnects_total",
+			Help: "Total client reconnect attempts after a dropped tunnel.",
+		}),
+	}
+
+	m.Registry.MustRegister(
+		m.TunnelsRegistered,
+		m.RequestsTotal,
+		m.RequestDuration,
+		m.BytesInTotal,
+		m.BytesOutTotal,
+		m.PendingRequests,
+		m.ReconnectsTotal,
+	)
+	return m
+}
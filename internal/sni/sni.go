@@ -0,0 +1,70 @@
+// Package sni peeks the SNI server name out of a TLS ClientHello without
+// terminating the handshake, so a raw TLS connection can be routed to a
+// tunnel service before any bytes are consumed from it.
+package sni
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// errPeeked aborts the handshake tls.Server performs purely to observe
+// the ClientHello; the connection is never meant to complete it.
+var errPeeked = errors.New("sni: client hello observed")
+
+// Peek reads just enough of conn to learn the ClientHello's SNI server
+// name, then returns a net.Conn that replays those bytes before falling
+// through to conn, so the caller can pipe the connection onward as if it
+// had never been peeked.
+func Peek(conn net.Conn) (net.Conn, string, error) {
+	var buf bytes.Buffer
+	teeConn := &teeConn{Conn: conn, r: io.TeeReader(conn, &buf)}
+
+	var serverName string
+	cfg := &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errPeeked
+		},
+	}
+	if err := tls.Server(teeConn, cfg).Handshake(); err == nil || !errors.Is(err, errPeeked) {
+		return nil, "", fmt.Errorf("sni: failed to read client hello: %w", err)
+	}
+	if serverName == "" {
+		return nil, "", fmt.Errorf("sni: client hello carried no server name")
+	}
+
+	return &peekedConn{Conn: conn, replay: bytes.NewReader(buf.Bytes())}, serverName, nil
+}
+
+// teeConn reads through r (which tees into a buffer) instead of directly
+// from the embedded net.Conn, so every byte consumed during the peek is
+// captured for later replay.
+type teeConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *teeConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// Write refuses every write, so the alert tls.Server sends when the peek
+// aborts its handshake never reaches the client.
+func (c *teeConn) Write(p []byte) (int, error) { return 0, io.ErrClosedPipe }
+
+// peekedConn replays the bytes consumed while peeking before resuming
+// reads from the underlying connection.
+type peekedConn struct {
+	net.Conn
+	replay *bytes.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	if c.replay.Len() > 0 {
+		return c.replay.Read(p)
+	}
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,16 @@
+// Package streamkind defines the one-byte tag a tunnel server writes at
+// the start of every yamux stream it opens, so the client knows whether
+// to speak HTTP on the stream or pipe it straight to a raw TCP socket.
+package streamkind
+
+// Kind is the first byte of a yamux stream, written by whichever side
+// opens the stream.
+type Kind byte
+
+const (
+	// HTTP streams carry one HTTP/1.1 request/response pair, written
+	// and read with the net/http wire format.
+	HTTP Kind = 'H'
+	// TCP streams are piped byte-for-byte to a local TCP connection.
+	TCP Kind = 'T'
+)
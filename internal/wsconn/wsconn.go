@@ -0,0 +1,66 @@
+// Package wsconn adapts a gorilla/websocket connection to the net.Conn
+// interface so that a stream multiplexer (yamux) can be layered on top
+// of it.
+package wsconn
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn wraps a *websocket.Conn as a net.Conn. Every Write is sent as a
+// single binary websocket message; Read transparently spans messages,
+// since yamux expects a plain byte stream and doesn't care about
+// message boundaries.
+type Conn struct {
+	*websocket.Conn
+	reader io.Reader
+}
+
+// New wraps conn for use as a net.Conn.
+func New(conn *websocket.Conn) *Conn {
+	return &Conn{Conn: conn}
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, r, err := c.Conn.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.reader = r
+		}
+		n, err := c.reader.Read(p)
+		if n > 0 {
+			return n, nil
+		}
+		if errors.Is(err, io.EOF) {
+			c.reader = nil
+			continue // move on to the next websocket message
+		}
+		if err != nil {
+			c.reader = nil
+			return 0, err
+		}
+	}
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.Conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// SetDeadline satisfies net.Conn; gorilla's websocket.Conn exposes
+// separate read/write deadlines instead of a combined one.
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.Conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.Conn.SetWriteDeadline(t)
+}